@@ -0,0 +1,180 @@
+// Package comparator provides typed, pluggable orderings over JSON
+// documents, keyed off a gojq path expression, in the spirit of gostl's
+// typed comparators. It backs the ?sort= query parameter on GET
+// /ns/{namespace} and GET /search/{namespace}.
+package comparator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// Comparator reports whether the value a gojq query extracts from document
+// a sorts strictly before the value it extracts from document b.
+type Comparator func(a, b interface{}) bool
+
+// Kind selects which builtin comparator By uses to compare extracted values.
+type Kind string
+
+const (
+	KindString Kind = "string"
+	KindInt    Kind = "int"
+	KindFloat  Kind = "float"
+	KindBool   Kind = "bool"
+	KindTime   Kind = "time"
+	// KindAuto dispatches on the extracted value's runtime type, trying a
+	// time.Time parse before falling back to string/float64/bool; it's what
+	// the HTTP layer uses when the caller supplies only a path, not a kind.
+	KindAuto Kind = "auto"
+)
+
+// Builder turns a parsed gojq path expression into a Comparator.
+type Builder func(path *gojq.Query) Comparator
+
+var registry = map[Kind]Builder{
+	KindString: stringComparator,
+	KindInt:    numberComparator,
+	KindFloat:  numberComparator,
+	KindBool:   boolComparator,
+	KindTime:   timeComparator,
+	KindAuto:   autoComparator,
+}
+
+// Register adds or overrides a named Builder, so callers can plug in
+// comparators for their own document shapes beyond the builtins above.
+func Register(kind Kind, b Builder) {
+	registry[kind] = b
+}
+
+// By parses path as a gojq expression (e.g. ".created_at") and returns a
+// Comparator that orders documents by kind's comparison of the value the
+// expression extracts from each one.
+func By(kind Kind, path string) (Comparator, error) {
+	builder, ok := registry[kind]
+	if !ok {
+		return nil, fmt.Errorf("comparator: unknown kind %q", kind)
+	}
+	query, err := gojq.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return builder(query), nil
+}
+
+func extract(query *gojq.Query, doc interface{}) (interface{}, bool) {
+	iter := query.Run(doc)
+	v, ok := iter.Next()
+	if !ok {
+		return nil, false
+	}
+	if _, isErr := v.(error); isErr {
+		return nil, false
+	}
+	return v, true
+}
+
+// orderMissing reports how to order a pair when one or both sides are
+// missing the extracted value, pushing documents without it to the end
+// regardless of kind. definitive is false when both sides agree (both
+// present or both missing), in which case the caller should fall through to
+// comparing the actual values.
+func orderMissing(aok, bok bool) (less bool, definitive bool) {
+	return aok && !bok, aok != bok
+}
+
+func stringComparator(query *gojq.Query) Comparator {
+	return func(a, b interface{}) bool {
+		av, aok := extract(query, a)
+		bv, bok := extract(query, b)
+		if less, definitive := orderMissing(aok, bok); definitive {
+			return less
+		}
+		as, _ := av.(string)
+		bs, _ := bv.(string)
+		return as < bs
+	}
+}
+
+func numberComparator(query *gojq.Query) Comparator {
+	return func(a, b interface{}) bool {
+		av, aok := extract(query, a)
+		bv, bok := extract(query, b)
+		if less, definitive := orderMissing(aok, bok); definitive {
+			return less
+		}
+		af, _ := av.(float64)
+		bf, _ := bv.(float64)
+		return af < bf
+	}
+}
+
+func boolComparator(query *gojq.Query) Comparator {
+	return func(a, b interface{}) bool {
+		av, aok := extract(query, a)
+		bv, bok := extract(query, b)
+		if less, definitive := orderMissing(aok, bok); definitive {
+			return less
+		}
+		ab, _ := av.(bool)
+		bb, _ := bv.(bool)
+		return !ab && bb
+	}
+}
+
+func timeComparator(query *gojq.Query) Comparator {
+	return func(a, b interface{}) bool {
+		av, aok := extract(query, a)
+		bv, bok := extract(query, b)
+		if less, definitive := orderMissing(aok, bok); definitive {
+			return less
+		}
+		at, aerr := parseTime(av)
+		bt, berr := parseTime(bv)
+		if aerr != nil || berr != nil {
+			return aerr == nil
+		}
+		return at.Before(bt)
+	}
+}
+
+// autoComparator dispatches on the runtime type of the extracted value, so
+// callers that only have a path (no declared kind) still get a sensible
+// ordering for strings (trying RFC3339 time first), json.Number-shaped
+// floats, and bools.
+func autoComparator(query *gojq.Query) Comparator {
+	return func(a, b interface{}) bool {
+		av, aok := extract(query, a)
+		bv, bok := extract(query, b)
+		if less, definitive := orderMissing(aok, bok); definitive {
+			return less
+		}
+		switch at := av.(type) {
+		case string:
+			if t, err := parseTime(av); err == nil {
+				if bt, err := parseTime(bv); err == nil {
+					return t.Before(bt)
+				}
+			}
+			bs, _ := bv.(string)
+			return at < bs
+		case float64:
+			bf, _ := bv.(float64)
+			return at < bf
+		case bool:
+			bb, _ := bv.(bool)
+			return !at && bb
+		default:
+			return false
+		}
+	}
+}
+
+func parseTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("comparator: value is not a string")
+	}
+	return time.Parse(time.RFC3339, s)
+}
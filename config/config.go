@@ -0,0 +1,118 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Config is the set of runtime-toggleable server settings, hot-reloadable
+// through a ConfigHandler without restarting the process.
+type Config struct {
+	SwaggerEnabled bool `json:"swagger_enabled"`
+	BrokerEnabled  bool `json:"broker_enabled"`
+	AuthEnabled    bool `json:"auth_enabled"`
+	RawSqlEnabled  bool `json:"raw_sql_enabled"`
+}
+
+// ErrFingerprintMismatch is returned by DoLockedAction when the caller's
+// fingerprint is stale, i.e. someone else changed the config first.
+var ErrFingerprintMismatch = errors.New("config fingerprint mismatch")
+
+// ConfigHandler guards concurrent edits to a Config with an optimistic
+// fingerprint lock, patterned after openbmclapi's config handler: a caller
+// must prove it last saw the config at a given fingerprint before its change
+// is accepted, so two admins racing to edit config don't silently clobber
+// each other.
+type ConfigHandler interface {
+	Current() Config
+	Fingerprint() string
+	DoLockedAction(fingerprint string, fn func(*Config) error) error
+}
+
+// FileHandler is a ConfigHandler backed by a JSON file on disk.
+type FileHandler struct {
+	path string
+
+	mu     sync.RWMutex
+	config Config
+}
+
+// NewFileHandler loads Config from path, failing if it can't be read.
+func NewFileHandler(path string) (*FileHandler, error) {
+	h := &FileHandler{path: path}
+	if err := h.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *FileHandler) Current() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.config
+}
+
+func (h *FileHandler) Fingerprint() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fingerprint(h.config)
+}
+
+func (h *FileHandler) DoLockedAction(callerFingerprint string, fn func(*Config) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if callerFingerprint != fingerprint(h.config) {
+		return ErrFingerprintMismatch
+	}
+
+	updated := h.config
+	if err := fn(&updated); err != nil {
+		return err
+	}
+	if err := persist(h.path, updated); err != nil {
+		return err
+	}
+	h.config = updated
+	return nil
+}
+
+// Reload re-reads the on-disk file, bypassing the fingerprint check - used
+// from a SIGHUP handler, where there's no caller-supplied fingerprint to
+// compare against.
+func (h *FileHandler) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reloadLocked()
+}
+
+func (h *FileHandler) reloadLocked() error {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	h.config = cfg
+	return nil
+}
+
+func persist(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, os.ModePerm)
+}
+
+func fingerprint(cfg Config) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
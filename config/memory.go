@@ -0,0 +1,43 @@
+package config
+
+import "sync"
+
+// MemoryHandler is a ConfigHandler with no backing file, for servers started
+// without a config path. Edits are fingerprint-locked the same as
+// FileHandler, but don't survive a restart and ignore SIGHUP.
+type MemoryHandler struct {
+	mu     sync.RWMutex
+	config Config
+}
+
+func NewMemoryHandler(initial Config) *MemoryHandler {
+	return &MemoryHandler{config: initial}
+}
+
+func (h *MemoryHandler) Current() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.config
+}
+
+func (h *MemoryHandler) Fingerprint() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fingerprint(h.config)
+}
+
+func (h *MemoryHandler) DoLockedAction(callerFingerprint string, fn func(*Config) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if callerFingerprint != fingerprint(h.config) {
+		return ErrFingerprintMismatch
+	}
+
+	updated := h.config
+	if err := fn(&updated); err != nil {
+		return err
+	}
+	h.config = updated
+	return nil
+}
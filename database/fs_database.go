@@ -1,30 +1,39 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type StorageDatabase struct {
 	RootDirPath string
+	// UploadTTL, when set, enables a background goroutine that removes
+	// upload sessions under _uploads/ that have been idle for longer than
+	// this duration. Zero disables the sweep.
+	UploadTTL time.Duration
 }
 
-func (s *StorageDatabase) Init() {
+func (s *StorageDatabase) Init(ctx context.Context) {
 	err := os.MkdirAll(s.RootDirPath, os.ModePerm)
 	if err != nil {
 		log.Fatalf("error on StorageDatabase Init: %v", err)
 	}
+	if s.UploadTTL > 0 {
+		go s.sweepStaleUploads(ctx)
+	}
 }
 
 func (s *StorageDatabase) Disconnect() {
 	// do nothing
 }
 
-func (s *StorageDatabase) Upsert(namespace string, key string, value []byte) *DbError {
+func (s *StorageDatabase) Upsert(ctx context.Context, namespace string, key string, value []byte) *DbError {
 	err := s.ensureNamespace(namespace)
 	if err != nil {
 		return &DbError{
@@ -47,7 +56,7 @@ func (s *StorageDatabase) Upsert(namespace string, key string, value []byte) *Db
 	return nil
 }
 
-func (s *StorageDatabase) Get(namespace string, key string) ([]byte, *DbError) {
+func (s *StorageDatabase) Get(ctx context.Context, namespace string, key string) ([]byte, *DbError) {
 	filePath := s.getFilePath(namespace, key)
 	bytes, err := os.ReadFile(filepath.Clean(filePath))
 	if err != nil {
@@ -60,7 +69,7 @@ func (s *StorageDatabase) Get(namespace string, key string) ([]byte, *DbError) {
 	}
 }
 
-func (s *StorageDatabase) GetAll(namespace string) (map[string][]byte, *DbError) {
+func (s *StorageDatabase) GetAll(ctx context.Context, namespace string) (map[string][]byte, *DbError) {
 	result := make(map[string][]byte)
 
 	docs, readDirErr := os.ReadDir(s.getNamespacePath(namespace))
@@ -71,13 +80,22 @@ func (s *StorageDatabase) GetAll(namespace string) (map[string][]byte, *DbError)
 		}
 	}
 	for _, doc := range docs {
+		select {
+		case <-ctx.Done():
+			return nil, &DbError{
+				ErrorCode: FILESYSTEM_ERROR,
+				Message:   ctx.Err().Error(),
+			}
+		default:
+		}
+
 		keyParts := strings.SplitN(doc.Name(), ".", 2)
 		if len(keyParts) != 2 || keyParts[1] != "json" {
 			continue
 		}
 		rawKey := keyParts[0]
 		var err *DbError
-		result[rawKey], err = s.Get(namespace, rawKey)
+		result[rawKey], err = s.Get(ctx, namespace, rawKey)
 		if err != nil {
 			return nil, err
 		}
@@ -86,7 +104,74 @@ func (s *StorageDatabase) GetAll(namespace string) (map[string][]byte, *DbError)
 	return result, nil
 }
 
-func (s *StorageDatabase) Delete(namespace string, key string) *DbError {
+// RangeAll reads namespace's directory entries in sorted (filename) order,
+// skipping past cursor, and returns up to limit keys plus the cursor to
+// resume from on the next call. The returned cursor names the last key
+// included on this page (matching paginateKeys' convention), not the first
+// excluded one, so the next call's skip-past-cursor logic resumes right
+// after it instead of re-excluding it. Unlike GetAll, it never holds the
+// whole namespace in memory at once, so it backs cursor-paginated listing
+// for large namespaces. A zero limit returns everything from cursor onward.
+func (s *StorageDatabase) RangeAll(ctx context.Context, namespace string, cursor string, limit int) (map[string][]byte, string, *DbError) {
+	result := make(map[string][]byte)
+
+	docs, readDirErr := os.ReadDir(s.getNamespacePath(namespace))
+	if readDirErr != nil {
+		return nil, "", &DbError{
+			ErrorCode: FILESYSTEM_ERROR,
+			Message:   readDirErr.Error(),
+		}
+	}
+
+	skipping := cursor != ""
+	lastKey := ""
+	count := 0
+	more := false
+	for _, doc := range docs {
+		select {
+		case <-ctx.Done():
+			return nil, "", &DbError{
+				ErrorCode: FILESYSTEM_ERROR,
+				Message:   ctx.Err().Error(),
+			}
+		default:
+		}
+
+		keyParts := strings.SplitN(doc.Name(), ".", 2)
+		if len(keyParts) != 2 || keyParts[1] != "json" {
+			continue
+		}
+		rawKey := keyParts[0]
+
+		if skipping {
+			if rawKey == cursor {
+				skipping = false
+			}
+			continue
+		}
+
+		if limit > 0 && count >= limit {
+			more = true
+			break
+		}
+
+		value, err := s.Get(ctx, namespace, rawKey)
+		if err != nil {
+			return nil, "", err
+		}
+		result[rawKey] = value
+		lastKey = rawKey
+		count++
+	}
+
+	nextCursor := ""
+	if more {
+		nextCursor = lastKey
+	}
+	return result, nextCursor, nil
+}
+
+func (s *StorageDatabase) Delete(ctx context.Context, namespace string, key string) *DbError {
 	filePath := s.getFilePath(namespace, key)
 
 	_, err := os.Stat(filePath)
@@ -108,7 +193,7 @@ func (s *StorageDatabase) Delete(namespace string, key string) *DbError {
 	return nil
 }
 
-func (s *StorageDatabase) DeleteAll(namespace string) *DbError {
+func (s *StorageDatabase) DeleteAll(ctx context.Context, namespace string) *DbError {
 	err := os.RemoveAll(s.getNamespacePath(namespace))
 	if err != nil {
 		return &DbError{
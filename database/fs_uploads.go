@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const uploadsDirName = "_uploads"
+
+// StartUpload opens a new resumable upload session and returns its id.
+func (s *StorageDatabase) StartUpload(ctx context.Context, namespace string, key string) (string, *DbError) {
+	uploadId, err := newUploadId()
+	if err != nil {
+		return "", &DbError{
+			ErrorCode: FILESYSTEM_ERROR,
+			Message:   err.Error(),
+		}
+	}
+	if err := os.MkdirAll(s.getUploadPath(uploadId), os.ModePerm); err != nil {
+		return "", &DbError{
+			ErrorCode: FILESYSTEM_ERROR,
+			Message:   err.Error(),
+		}
+	}
+	return uploadId, nil
+}
+
+// AppendUpload appends chunk to uploadId's staging file at offset, failing if
+// offset doesn't line up with the bytes already received, and returns the
+// new total size.
+func (s *StorageDatabase) AppendUpload(ctx context.Context, uploadId string, offset int64, chunk []byte) (int64, *DbError) {
+	uploadDir := s.getUploadPath(uploadId)
+	if _, err := os.Stat(uploadDir); err != nil {
+		return 0, &DbError{
+			ErrorCode: ID_NOT_FOUND,
+			Message:   fmt.Sprintf("unknown upload '%v'", uploadId),
+		}
+	}
+
+	dataPath := filepath.Join(uploadDir, "data")
+	current := int64(0)
+	if info, err := os.Stat(dataPath); err == nil {
+		current = info.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+	if offset != current {
+		return 0, &DbError{
+			ErrorCode: FILESYSTEM_ERROR,
+			Message:   fmt.Sprintf("out-of-order chunk: expected offset %d, got %d", current, offset),
+		}
+	}
+
+	f, err := os.OpenFile(dataPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return 0, &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+	defer f.Close()
+	if _, err := f.Write(chunk); err != nil {
+		return 0, &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+
+	return current + int64(len(chunk)), nil
+}
+
+// FinishUpload verifies the assembled data against digest and returns it for
+// the caller to validate and Upsert. The staging directory is only removed
+// on success; a digest mismatch leaves the session and its staged bytes
+// intact, so a client that hadn't sent every chunk yet can PATCH the rest and
+// re-PUT. Staged bytes can't be rewritten (AppendUpload only accepts an
+// offset equal to the current size), and CancelUpload is the only way to
+// discard a session outright.
+func (s *StorageDatabase) FinishUpload(ctx context.Context, uploadId string, digest string) ([]byte, *DbError) {
+	uploadDir := s.getUploadPath(uploadId)
+	data, err := os.ReadFile(filepath.Clean(filepath.Join(uploadDir, "data")))
+	if err != nil {
+		return nil, &DbError{
+			ErrorCode: ID_NOT_FOUND,
+			Message:   fmt.Sprintf("unknown upload '%v'", uploadId),
+		}
+	}
+
+	if err := verifyDigest(data, digest); err != nil {
+		return nil, &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+
+	os.RemoveAll(uploadDir)
+	return data, nil
+}
+
+// CancelUpload discards an in-progress upload session.
+func (s *StorageDatabase) CancelUpload(ctx context.Context, uploadId string) *DbError {
+	if err := os.RemoveAll(s.getUploadPath(uploadId)); err != nil {
+		return &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *StorageDatabase) getUploadPath(uploadId string) string {
+	return filepath.Join(s.RootDirPath, uploadsDirName, uploadId)
+}
+
+// sweepStaleUploads periodically removes upload directories that have been
+// idle for longer than s.UploadTTL, until ctx is done.
+func (s *StorageDatabase) sweepStaleUploads(ctx context.Context) {
+	ticker := time.NewTicker(s.UploadTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.removeStaleUploads()
+		}
+	}
+}
+
+func (s *StorageDatabase) removeStaleUploads() {
+	root := filepath.Join(s.RootDirPath, uploadsDirName)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-s.UploadTTL)
+	for _, entry := range entries {
+		idleSince, err := uploadIdleSince(root, entry)
+		if err != nil || idleSince.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			log.Printf("error removing stale upload '%v': %v\n", entry.Name(), err)
+		}
+	}
+}
+
+// uploadIdleSince is the last time an upload session actually received data:
+// the staging data file's mtime, which is updated by every AppendUpload,
+// falling back to the upload directory's own mtime for a session that
+// hasn't received its first chunk yet.
+func uploadIdleSince(root string, entry os.DirEntry) (time.Time, error) {
+	if info, err := os.Stat(filepath.Join(root, entry.Name(), "data")); err == nil {
+		return info.ModTime(), nil
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func verifyDigest(data []byte, digest string) error {
+	const prefix = "sha256:"
+	if !strings.HasPrefix(digest, prefix) {
+		return fmt.Errorf("unsupported digest '%v', only sha256 is supported", digest)
+	}
+	sum := sha256.Sum256(data)
+	got := hex.EncodeToString(sum[:])
+	want := strings.TrimPrefix(digest, prefix)
+	if got != want {
+		return fmt.Errorf("digest mismatch: expected %v, got %v", want, got)
+	}
+	return nil
+}
+
+func newUploadId() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
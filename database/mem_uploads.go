@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemoryUploads is a default, in-memory implementation of the resumable
+// upload methods (StartUpload/AppendUpload/FinishUpload/CancelUpload). A
+// Database backend that has no natural place to stage partial uploads (i.e.
+// anything that isn't a filesystem) can embed it to satisfy those methods
+// without reimplementing the chunk bookkeeping; uploads simply don't survive
+// a process restart.
+type MemoryUploads struct {
+	mu      sync.Mutex
+	staging map[string]*stagedUpload
+}
+
+type stagedUpload struct {
+	namespace string
+	key       string
+	data      []byte
+}
+
+func (m *MemoryUploads) StartUpload(ctx context.Context, namespace string, key string) (string, *DbError) {
+	uploadId, err := newUploadId()
+	if err != nil {
+		return "", &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.staging == nil {
+		m.staging = make(map[string]*stagedUpload)
+	}
+	m.staging[uploadId] = &stagedUpload{namespace: namespace, key: key}
+	return uploadId, nil
+}
+
+func (m *MemoryUploads) AppendUpload(ctx context.Context, uploadId string, offset int64, chunk []byte) (int64, *DbError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, ok := m.staging[uploadId]
+	if !ok {
+		return 0, &DbError{ErrorCode: ID_NOT_FOUND, Message: fmt.Sprintf("unknown upload '%v'", uploadId)}
+	}
+	if offset != int64(len(upload.data)) {
+		return 0, &DbError{
+			ErrorCode: FILESYSTEM_ERROR,
+			Message:   fmt.Sprintf("out-of-order chunk: expected offset %d, got %d", len(upload.data), offset),
+		}
+	}
+	upload.data = append(upload.data, chunk...)
+	return int64(len(upload.data)), nil
+}
+
+// FinishUpload verifies the assembled data against digest and returns it for
+// the caller to validate and Upsert. The session is only discarded on
+// success; a digest mismatch leaves it staged, so a client that hadn't sent
+// every chunk yet can PATCH the rest and re-PUT. Staged bytes can't be
+// rewritten (AppendUpload only accepts an offset equal to the current size),
+// and CancelUpload is the only way to discard a session outright.
+func (m *MemoryUploads) FinishUpload(ctx context.Context, uploadId string, digest string) ([]byte, *DbError) {
+	m.mu.Lock()
+	upload, ok := m.staging[uploadId]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, &DbError{ErrorCode: ID_NOT_FOUND, Message: fmt.Sprintf("unknown upload '%v'", uploadId)}
+	}
+	if err := verifyDigest(upload.data, digest); err != nil {
+		return nil, &DbError{ErrorCode: FILESYSTEM_ERROR, Message: err.Error()}
+	}
+
+	m.mu.Lock()
+	delete(m.staging, uploadId)
+	m.mu.Unlock()
+	return upload.data, nil
+}
+
+func (m *MemoryUploads) CancelUpload(ctx context.Context, uploadId string) *DbError {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.staging, uploadId)
+	return nil
+}
@@ -0,0 +1,233 @@
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rehacktive/caffeine/database"
+)
+
+// Namespace is the reserved Database namespace operations are persisted
+// under, in the same spirit as users._users / users._acl.
+const Namespace = "_operations"
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+const (
+	EventStarted  = "OPERATION_STARTED"
+	EventProgress = "OPERATION_PROGRESS"
+	EventDone     = "OPERATION_DONE"
+	EventFailed   = "OPERATION_FAILED"
+)
+
+// Operation is an async job, e.g. a DeleteAll over a huge namespace or an
+// OpenAPI generation run, tracked so its caller can poll or cancel it
+// instead of holding a connection open past the server's write timeout.
+type Operation struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Status    Status      `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Progress  int         `json:"progress"`
+	Result    interface{} `json:"result,omitempty"`
+	Err       string      `json:"error,omitempty"`
+}
+
+// Store is the subset of service.Database that operations needs, declared
+// here rather than imported to avoid a service<->operations import cycle,
+// the same approach users.Store takes.
+type Store interface {
+	Upsert(ctx context.Context, namespace string, key string, value []byte) *database.DbError
+	Get(ctx context.Context, namespace string, key string) ([]byte, *database.DbError)
+	GetAll(ctx context.Context, namespace string) (map[string][]byte, *database.DbError)
+	Delete(ctx context.Context, namespace string, key string) *database.DbError
+}
+
+// EventPublisher lets a Manager announce state transitions (e.g. onto the
+// Broker's SSE channel) without depending on the service package.
+type EventPublisher interface {
+	Publish(event string, operationId string, operation Operation)
+}
+
+// Work is the unit of async work a Manager runs. It should honor ctx
+// cancellation and call progress as it makes headway.
+type Work func(ctx context.Context, progress func(percent int)) (interface{}, error)
+
+// Manager runs and tracks Operations, persisting their state through a
+// Store and publishing transitions through an EventPublisher.
+type Manager struct {
+	db     Store
+	events EventPublisher
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewManager(db Store, events EventPublisher) *Manager {
+	return &Manager{
+		db:      db,
+		events:  events,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start creates an Operation and runs work in the background, returning the
+// Operation's initial (running) state immediately.
+func (m *Manager) Start(ctx context.Context, opType string, work Work) (Operation, error) {
+	id, err := newOperationId()
+	if err != nil {
+		return Operation{}, err
+	}
+
+	now := time.Now()
+	op := Operation{
+		ID:        id,
+		Type:      opType,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.save(ctx, op); err != nil {
+		return Operation{}, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.cancels[id] = cancel
+	m.mu.Unlock()
+
+	m.publish(EventStarted, op)
+	go m.run(runCtx, cancel, id, work)
+
+	return op, nil
+}
+
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, id string, work Work) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.cancels, id)
+		m.mu.Unlock()
+		cancel()
+	}()
+
+	result, err := work(ctx, func(percent int) {
+		op, getErr := m.Get(context.Background(), id)
+		if getErr != nil {
+			return
+		}
+		op.Progress = percent
+		op.UpdatedAt = time.Now()
+		if saveErr := m.save(context.Background(), op); saveErr != nil {
+			return
+		}
+		m.publish(EventProgress, op)
+	})
+
+	op, getErr := m.Get(context.Background(), id)
+	if getErr != nil {
+		return
+	}
+	op.UpdatedAt = time.Now()
+	if err != nil {
+		if ctx.Err() != nil {
+			op.Status = StatusCancelled
+			op.Err = ctx.Err().Error()
+		} else {
+			op.Status = StatusFailed
+			op.Err = err.Error()
+		}
+		if saveErr := m.save(context.Background(), op); saveErr != nil {
+			return
+		}
+		m.publish(EventFailed, op)
+		return
+	}
+
+	op.Status = StatusDone
+	op.Result = result
+	if saveErr := m.save(context.Background(), op); saveErr != nil {
+		return
+	}
+	m.publish(EventDone, op)
+}
+
+func (m *Manager) Get(ctx context.Context, id string) (Operation, error) {
+	data, dbErr := m.db.Get(ctx, Namespace, id)
+	if dbErr != nil {
+		return Operation{}, dbErr
+	}
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return Operation{}, err
+	}
+	return op, nil
+}
+
+func (m *Manager) GetAll(ctx context.Context) ([]Operation, error) {
+	all, dbErr := m.db.GetAll(ctx, Namespace)
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	ops := make([]Operation, 0, len(all))
+	for _, data := range all {
+		var op Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// Cancel cancels a still-running operation's context, or deletes its record
+// if it has already finished.
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	cancel, running := m.cancels[id]
+	m.mu.Unlock()
+	if running {
+		cancel()
+		return nil
+	}
+	if dbErr := m.db.Delete(ctx, Namespace, id); dbErr != nil {
+		return dbErr
+	}
+	return nil
+}
+
+func (m *Manager) save(ctx context.Context, op Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if dbErr := m.db.Upsert(ctx, Namespace, op.ID, data); dbErr != nil {
+		return dbErr
+	}
+	return nil
+}
+
+func (m *Manager) publish(event string, op Operation) {
+	if m.events != nil {
+		m.events.Publish(event, op.ID, op)
+	}
+}
+
+func newOperationId() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rehacktive/caffeine/config"
+)
+
+// initConfig loads the hot-reloadable config (see ApplyConfig) from
+// ConfigPath, or from defaultConfigPath when ConfigPath is unset. If that file
+// can't be read or parsed, it seeds an in-memory one from the bootstrap
+// SwaggerEnabled/BrokerEnabled/AuthEnabled/RawSqlEnabled fields instead.
+func (s *Server) initConfig() {
+	path := s.ConfigPath
+	if path == "" {
+		path = defaultConfigPath
+	}
+
+	handler, err := config.NewFileHandler(path)
+	if err != nil {
+		log.Printf("no config file at '%s', starting from current flags: %v\n", path, err)
+		handler = config.NewMemoryHandler(config.Config{
+			SwaggerEnabled: s.SwaggerEnabled,
+			BrokerEnabled:  s.BrokerEnabled,
+			AuthEnabled:    s.AuthEnabled,
+			RawSqlEnabled:  s.RawSqlEnabled,
+		})
+	}
+	s.config = handler
+	s.syncConfigFields()
+}
+
+// syncConfigFields mirrors the live config onto the Server's bootstrap
+// fields, so existing code that still reads them sees the current value.
+func (s *Server) syncConfigFields() {
+	cfg := s.config.Current()
+	s.SwaggerEnabled = cfg.SwaggerEnabled
+	s.BrokerEnabled = cfg.BrokerEnabled
+	s.AuthEnabled = cfg.AuthEnabled
+	s.RawSqlEnabled = cfg.RawSqlEnabled
+}
+
+// ApplyConfig applies fn to the server's config, but only if fingerprint
+// matches the config currently in effect; runtime edits - whether from PUT
+// /config or a future schema-migration tool - land only through here, so two
+// admins editing concurrently can't silently clobber one another. A SIGHUP
+// reload (see listenForConfigReload) is the one exception: it replaces the
+// config from disk without a fingerprint.
+func (s *Server) ApplyConfig(fingerprint string, fn func(*config.Config) error) error {
+	if err := s.config.DoLockedAction(fingerprint, fn); err != nil {
+		return err
+	}
+	s.syncConfigFields()
+	return nil
+}
+
+// listenForConfigReload re-reads the on-disk config file on SIGHUP, so
+// operators can flip auth/broker without restarting. It goes through
+// FileHandler.Reload, which holds the same lock as ApplyConfig's
+// DoLockedAction but skips the fingerprint check. It's a no-op when the
+// config isn't file-backed.
+func (s *Server) listenForConfigReload() {
+	reloader, ok := s.config.(*config.FileHandler)
+	if !ok {
+		return
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := reloader.Reload(); err != nil {
+				log.Printf("error reloading config on SIGHUP: %v\n", err)
+				continue
+			}
+			s.syncConfigFields()
+			log.Println("reloaded config on SIGHUP")
+		}
+	}()
+}
+
+// gateConfig wraps next so it only runs while enabled(s.config.Current())
+// holds, responding 404 otherwise. Used to let swagger/broker be toggled at
+// runtime despite being wired into the router once at startup.
+func (s *Server) gateConfig(enabled func(config.Config) bool, name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enabled(s.config.Current()) {
+			respondWithError(w, http.StatusNotFound, name+" is disabled")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gateAuthMiddleware skips mw entirely while auth is disabled in the live
+// config, letting AuthEnabled be toggled at runtime even though the
+// middleware chain itself is only assembled once at startup.
+func (s *Server) gateAuthMiddleware(mw mux.MiddlewareFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.config.Current().AuthEnabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// configPatch is PUT /config's request body: every field is optional, so a
+// caller can flip one toggle without having to first read back and resend
+// every other one (and risk zeroing it out if they don't).
+type configPatch struct {
+	SwaggerEnabled *bool `json:"swagger_enabled"`
+	BrokerEnabled  *bool `json:"broker_enabled"`
+	AuthEnabled    *bool `json:"auth_enabled"`
+	RawSqlEnabled  *bool `json:"raw_sql_enabled"`
+}
+
+// apply merges the fields p sets onto cfg, leaving the rest untouched.
+func (p configPatch) apply(cfg *config.Config) {
+	if p.SwaggerEnabled != nil {
+		cfg.SwaggerEnabled = *p.SwaggerEnabled
+	}
+	if p.BrokerEnabled != nil {
+		cfg.BrokerEnabled = *p.BrokerEnabled
+	}
+	if p.AuthEnabled != nil {
+		cfg.AuthEnabled = *p.AuthEnabled
+	}
+	if p.RawSqlEnabled != nil {
+		cfg.RawSqlEnabled = *p.RawSqlEnabled
+	}
+}
+
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := json.Marshal(s.config.Current())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("ETag", s.config.Fingerprint())
+		respondWithJSON(w, http.StatusOK, string(data))
+
+	case http.MethodPut:
+		ifMatch := r.Header.Get("If-Match")
+
+		defer r.Body.Close()
+		r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+		var patch configPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if patch.AuthEnabled != nil && *patch.AuthEnabled && !s.authAvailable {
+			respondWithError(w, http.StatusConflict, "auth cannot be enabled: no JWT public key was loaded at startup")
+			return
+		}
+
+		err := s.ApplyConfig(ifMatch, func(cfg *config.Config) error {
+			patch.apply(cfg)
+			return nil
+		})
+		if errors.Is(err, config.ErrFingerprintMismatch) {
+			respondWithError(w, http.StatusConflict, "config fingerprint does not match current config; re-fetch GET /config and retry")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		data, err := json.Marshal(s.config.Current())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("ETag", s.config.Fingerprint())
+		respondWithJSON(w, http.StatusOK, string(data))
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rehacktive/caffeine/comparator"
+	"github.com/rehacktive/caffeine/database"
+)
+
+// Rangeable is implemented by Database backends that can stream a namespace
+// in sorted (by key) order a page at a time, without loading it all into
+// memory; StorageDatabase is one. It's checked with a type assertion rather
+// than added to Database itself, the same way listenForConfigReload checks
+// for *config.FileHandler - backends that don't implement it just fall back
+// to GetAll plus an in-memory sort.
+type Rangeable interface {
+	RangeAll(ctx context.Context, namespace string, cursor string, limit int) (map[string][]byte, string, *database.DbError)
+}
+
+// listParams are the ?limit/cursor/sort/order/project query parameters
+// shared by GET /ns/{namespace} and GET /search/{namespace}.
+type listParams struct {
+	limit   int
+	cursor  string
+	sort    string
+	order   string
+	project []string
+}
+
+func parseListParams(r *http.Request) listParams {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	p := listParams{
+		limit:  limit,
+		cursor: q.Get("cursor"),
+		sort:   q.Get("sort"),
+		order:  q.Get("order"),
+	}
+	if proj := q.Get("project"); proj != "" {
+		p.project = strings.Split(proj, ",")
+	}
+	return p
+}
+
+// loadNamespace fetches namespace's raw documents, using Rangeable.RangeAll
+// to stream a single page when possible. forceFull skips that path (e.g.
+// when the caller still needs to filter ownership over the whole namespace),
+// and so does an explicit sort or a desc order, since RangeAll only walks
+// keys in ascending order. paged reports whether the result is already a
+// bounded, cursor-addressable page, so callers know whether they still need
+// to paginate it themselves.
+func (s *Server) loadNamespace(ctx context.Context, namespace string, p listParams, forceFull bool) (raw map[string][]byte, nextCursor string, paged bool, dbErr *database.DbError) {
+	if !forceFull && p.sort == "" && p.order != "desc" {
+		if rangeable, ok := s.db.(Rangeable); ok {
+			raw, nextCursor, dbErr = rangeable.RangeAll(ctx, namespace, p.cursor, p.limit)
+			return raw, nextCursor, true, dbErr
+		}
+	}
+	raw, dbErr = s.db.GetAll(ctx, namespace)
+	return raw, "", false, dbErr
+}
+
+// paginateKeys applies cursor/limit over an already-ordered key slice, used
+// for the in-memory sort path where RangeAll's own cursor doesn't apply.
+func paginateKeys(keys []string, cursor string, limit int) ([]string, string) {
+	start := 0
+	if cursor != "" {
+		for i, k := range keys {
+			if k == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start >= len(keys) {
+		return nil, ""
+	}
+	end := len(keys)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	page := keys[start:end]
+	next := ""
+	if end < len(keys) {
+		next = page[len(page)-1]
+	}
+	return page, next
+}
+
+// paginateEntries is paginateKeys' counterpart for search results, which
+// don't have a stable, unique string key per entry (a single document can
+// produce several matches), so the cursor is just an integer offset.
+func paginateEntries(entries []searchEntry, cursor string, limit int) ([]searchEntry, string) {
+	start := 0
+	if n, err := strconv.Atoi(cursor); err == nil && n > 0 {
+		start = n
+	}
+	if start >= len(entries) {
+		return nil, ""
+	}
+	end := len(entries)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	next := ""
+	if end < len(entries) {
+		next = strconv.Itoa(end)
+	}
+	return entries[start:end], next
+}
+
+// sortKeys orders keys (parallel to the parsed documents in docs), honoring
+// p.order. With no p.sort it falls back to plain key order, the same
+// ordering RangeAll uses, so pagination over a GetAll fallback stays
+// resumable; an unparsable p.sort falls back the same way.
+func sortKeys(keys []string, docs map[string]interface{}, p listParams) {
+	cmp, err := comparatorFor(p)
+	if err != nil {
+		sort.Strings(keys)
+	} else {
+		sort.SliceStable(keys, func(i, j int) bool { return cmp(docs[keys[i]], docs[keys[j]]) })
+	}
+	if p.order == "desc" {
+		reverseStrings(keys)
+	}
+}
+
+// sortEntries is sortKeys' counterpart for search results, which sort on
+// each match's value rather than a parsed document keyed by name.
+func sortEntries(entries []searchEntry, p listParams) {
+	cmp, err := comparatorFor(p)
+	if err != nil {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	} else {
+		sort.SliceStable(entries, func(i, j int) bool { return cmp(entries[i].Value, entries[j].Value) })
+	}
+	if p.order == "desc" {
+		for l, r := 0, len(entries)-1; l < r; l, r = l+1, r-1 {
+			entries[l], entries[r] = entries[r], entries[l]
+		}
+	}
+}
+
+// comparatorFor builds the comparator p.sort selects, or an error if p.sort
+// is empty or doesn't parse as a gojq path.
+func comparatorFor(p listParams) (comparator.Comparator, error) {
+	if p.sort == "" {
+		return nil, errNoSort
+	}
+	return comparator.By(comparator.KindAuto, p.sort)
+}
+
+var errNoSort = fmt.Errorf("no sort expression given")
+
+func reverseStrings(s []string) {
+	for l, r := 0, len(s)-1; l < r; l, r = l+1, r-1 {
+		s[l], s[r] = s[r], s[l]
+	}
+}
+
+// project narrows doc to the top-level fields named in fields; a nil or
+// empty fields, or a doc that isn't a JSON object, is returned unchanged.
+func project(doc interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return doc
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return doc
+	}
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := obj[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+// listResponse is the shape returned by GET /ns/{namespace}.
+type listResponse struct {
+	Items      map[string]interface{} `json:"items"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// searchEntry is one match from GET /search/{namespace}; a single document
+// can contribute more than one, since the filter is an arbitrary gojq query.
+type searchEntry struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// searchResponse is the shape returned by GET /search/{namespace}.
+type searchResponse struct {
+	Items      []searchEntry `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rehacktive/caffeine/operations"
+)
+
+// operationsPublisher adapts Server.Notify to operations.EventPublisher so
+// state transitions land on the same Broker SSE channel as regular item
+// events, letting clients subscribe to a specific operation id.
+type operationsPublisher struct {
+	server *Server
+}
+
+func (p operationsPublisher) Publish(event string, operationId string, op operations.Operation) {
+	p.server.Notify(BrokerEvent{
+		Event:     event,
+		Namespace: operations.Namespace,
+		Key:       operationId,
+		Value:     op,
+	})
+}
+
+func (s *Server) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	ops, err := s.operations.GetAll(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, string(data))
+}
+
+func (s *Server) operationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	switch r.Method {
+	case http.MethodGet:
+		op, err := s.operations.Get(r.Context(), id)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		data, err := json.Marshal(op)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, string(data))
+	case http.MethodDelete:
+		if err := s.operations.Cancel(r.Context(), id); err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusAccepted, "{}")
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
@@ -15,35 +18,54 @@ import (
 	"github.com/itchyny/gojq"
 	"github.com/xeipuuv/gojsonschema"
 
+	"github.com/rehacktive/caffeine/config"
 	"github.com/rehacktive/caffeine/database"
+	"github.com/rehacktive/caffeine/operations"
+	"github.com/rehacktive/caffeine/users"
 )
 
+// Database is the storage backend a Server runs against. A backend may also
+// implement Rangeable for cursor-paginated listing without loading a whole
+// namespace into memory; that's optional, checked with a type assertion,
+// since GetAll below already covers the same data less efficiently.
 type Database interface {
-	Init()
+	Init(ctx context.Context)
 	Disconnect()
-	Upsert(namespace string, key string, value []byte) *database.DbError
-	Get(namespace string, key string) ([]byte, *database.DbError)
-	GetAll(namespace string) (map[string][]byte, *database.DbError)
-	Delete(namespace string, key string) *database.DbError
-	DeleteAll(namespace string) *database.DbError
+	Upsert(ctx context.Context, namespace string, key string, value []byte) *database.DbError
+	Get(ctx context.Context, namespace string, key string) ([]byte, *database.DbError)
+	GetAll(ctx context.Context, namespace string) (map[string][]byte, *database.DbError)
+	Delete(ctx context.Context, namespace string, key string) *database.DbError
+	DeleteAll(ctx context.Context, namespace string) *database.DbError
 	GetNamespaces() []string
+	StartUpload(ctx context.Context, namespace string, key string) (string, *database.DbError)
+	AppendUpload(ctx context.Context, uploadId string, offset int64, chunk []byte) (int64, *database.DbError)
+	FinishUpload(ctx context.Context, uploadId string, digest string) ([]byte, *database.DbError)
+	CancelUpload(ctx context.Context, uploadId string) *database.DbError
 }
 
 const (
-	NamespacePattern = "/ns/{namespace:[a-zA-Z0-9]+}"
-	KeyValuePattern  = "/ns/{namespace:[a-zA-Z0-9]+}/{key:[a-zA-Z0-9]+}"
-	SearchPattern    = "/search/{namespace:[a-zA-Z0-9]+}"
-	SchemaPattern    = "/schema/{namespace:[a-zA-Z0-9]+}"
-	OpenAPIPattern   = "/{openapi|swagger}.json"
-	BrokerPattern    = "/broker"
-	SwaggerUIPattern = "/swaggerui/"
-	SchemaId         = "_schema"
+	NamespacePattern  = "/ns/{namespace:[a-zA-Z0-9]+}"
+	KeyValuePattern   = "/ns/{namespace:[a-zA-Z0-9]+}/{key:[a-zA-Z0-9]+}"
+	SearchPattern     = "/search/{namespace:[a-zA-Z0-9]+}"
+	SchemaPattern     = "/schema/{namespace:[a-zA-Z0-9]+}"
+	UsersPattern      = "/users"
+	UserPattern       = "/users/{id}"
+	UploadsPattern    = "/ns/{namespace:[a-zA-Z0-9]+}/{key:[a-zA-Z0-9]+}/uploads"
+	UploadPattern     = "/ns/{namespace:[a-zA-Z0-9]+}/{key:[a-zA-Z0-9]+}/uploads/{uuid}"
+	OperationsPattern = "/operations"
+	OperationPattern  = "/operations/{id}"
+	ConfigPattern     = "/config"
+	OpenAPIPattern    = "/{openapi|swagger}.json"
+	BrokerPattern     = "/broker"
+	SwaggerUIPattern  = "/swaggerui/"
+	SchemaId          = "_schema"
 
 	EVENT_ITEM_ADDED        = "ITEM_ADDED"
 	EVENT_ITEM_DELETED      = "ITEM_DELETED"
 	EVENT_NAMESPACE_DELETED = "NAMESPACE_DELETED"
 
-	certsPublicKey = "./certs/public-cert.pem"
+	certsPublicKey    = "./certs/public-cert.pem"
+	defaultConfigPath = "./config.json"
 )
 
 var (
@@ -56,50 +78,76 @@ type Server struct {
 	BrokerEnabled  bool
 	AuthEnabled    bool
 	RawSqlEnabled  bool
-
-	router *mux.Router
-	broker *Broker
-	db     Database
+	RequestTimeout time.Duration
+	// ConfigPath, when set, is a JSON file backing the hot-reloadable config
+	// (see ApplyConfig); it seeds SwaggerEnabled/BrokerEnabled/AuthEnabled/
+	// RawSqlEnabled above and is re-read on SIGHUP. When unset,
+	// defaultConfigPath is tried instead; if neither file can be loaded, the
+	// same toggles are still available through GET/PUT /config, just in-memory.
+	ConfigPath string
+
+	router     *mux.Router
+	broker     *Broker
+	db         Database
+	users      *users.Manager
+	operations *operations.Manager
+	config     config.ConfigHandler
+	// authAvailable reports whether a JWT public key was loaded at startup, so
+	// PUT /config can refuse to flip AuthEnabled on at runtime when there is no
+	// verifier to actually enforce it with.
+	authAvailable bool
 }
 
 func (s *Server) Init(db Database) {
 	s.db = db
-	s.db.Init()
+	s.db.Init(context.Background())
+	s.users = users.NewManager(db)
+	s.operations = operations.NewManager(db, operationsPublisher{server: s})
+	s.initConfig()
 
 	s.router = mux.NewRouter()
 	s.router.HandleFunc("/ns", s.homeHandler)
-	s.router.HandleFunc(NamespacePattern, s.namespaceHandler).Methods(http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions)
-	s.router.HandleFunc(KeyValuePattern, s.keyValueHandler).Methods(http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions)
-	s.router.HandleFunc(SearchPattern, s.searchHandler).Queries("filter", "{filter}")
-	s.router.HandleFunc(SchemaPattern, s.schemaHandler)
-
-	if s.SwaggerEnabled {
-		s.router.HandleFunc(OpenAPIPattern, s.openAPIHandler)
-		s.router.PathPrefix(SwaggerUIPattern).Handler(http.StripPrefix(SwaggerUIPattern, http.FileServer(http.Dir("./swagger-ui/"))))
-		log.Println("swagger extension enabled")
-
-	}
-
-	if s.BrokerEnabled {
-		s.broker = NewServer()
-		s.router.Handle(BrokerPattern, s.broker)
-		log.Println("broker extension enabled")
-	}
+	s.router.HandleFunc(NamespacePattern, s.withTimeout(s.namespaceHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(KeyValuePattern, s.withTimeout(s.keyValueHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(SearchPattern, s.withTimeout(s.searchHandler)).Queries("filter", "{filter}")
+	s.router.HandleFunc(SchemaPattern, s.withTimeout(s.schemaHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(UsersPattern, s.withTimeout(s.usersHandler)).Methods(http.MethodPost, http.MethodOptions)
+	s.router.HandleFunc(UserPattern, s.withTimeout(s.userHandler)).Methods(http.MethodGet, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(UploadsPattern, s.withTimeout(s.startUploadHandler)).Methods(http.MethodPost, http.MethodOptions)
+	s.router.HandleFunc(UploadPattern, s.withTimeout(s.uploadHandler)).Methods(http.MethodPatch, http.MethodPut, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(OperationsPattern, s.withTimeout(s.operationsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	s.router.HandleFunc(OperationPattern, s.withTimeout(s.operationHandler)).Methods(http.MethodGet, http.MethodDelete, http.MethodOptions)
+	s.router.HandleFunc(ConfigPattern, s.withTimeout(s.configHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+
+	// Swagger and the broker are registered unconditionally so that
+	// ApplyConfig can flip them on or off at runtime; each consults the live
+	// config rather than the Server fields captured at startup. Auth, below,
+	// does the same, but is only registered when a JWT public key loads.
+	s.router.HandleFunc(OpenAPIPattern, s.openAPIHandler)
+	s.router.PathPrefix(SwaggerUIPattern).Handler(s.gateConfig(func(c config.Config) bool { return c.SwaggerEnabled }, "swagger",
+		http.StripPrefix(SwaggerUIPattern, http.FileServer(http.Dir("./swagger-ui/")))))
+
+	s.broker = NewServer()
+	s.router.Handle(BrokerPattern, s.gateConfig(func(c config.Config) bool { return c.BrokerEnabled }, "broker", s.broker))
 
 	s.router.Use(mux.CORSMethodMiddleware(s.router))
 
-	if s.AuthEnabled {
-		verifyBytes, err := os.ReadFile(certsPublicKey)
-		if err != nil {
+	if verifyBytes, err := os.ReadFile(certsPublicKey); err != nil {
+		if s.config.Current().AuthEnabled {
 			log.Fatalf("auth required but error on reading public key for JWT: %v", err)
 		}
-		middleware := JWTAuthMiddleware{
+		log.Printf("no public key for JWT at '%s': authentication can't be enabled at runtime: %v\n", certsPublicKey, err)
+	} else {
+		jwtMiddleware := JWTAuthMiddleware{
 			VerifyBytes: verifyBytes,
 		}
-		s.router.Use(middleware.GetMiddleWare(s.router))
-		log.Println("authentication middleware enabled")
+		s.router.Use(s.gateAuthMiddleware(s.tokenOrJWTMiddleware(jwtMiddleware.GetMiddleWare(s.router))))
+		s.authAvailable = true
+		log.Println("authentication middleware enabled (bearer tokens and JWTs)")
 	}
 
+	s.listenForConfigReload()
+
 	srv := &http.Server{
 		Handler:      handlers.CompressHandlerLevel(s.router, gzip.BestSpeed),
 		Addr:         s.Address,
@@ -110,6 +158,20 @@ func (s *Server) Init(db Database) {
 	log.Fatal(srv.ListenAndServe())
 }
 
+// withTimeout bounds a handler's context to s.RequestTimeout, when set, so that
+// long-running operations (e.g. a full-namespace search or delete) can be
+// aborted instead of holding a connection past the server's write timeout.
+func (s *Server) withTimeout(next http.HandlerFunc) http.HandlerFunc {
+	if s.RequestTimeout <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.RequestTimeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
 func (s *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 	namespaces, err := jsonWrapper(s.db.GetNamespaces())
 	if err != nil {
@@ -135,7 +197,9 @@ func (s *Server) namespaceHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		respondWithError(w, http.StatusNotImplemented, "cannot POST to this endpoint!")
 	case http.MethodGet:
-		data, dbErr := s.db.GetAll(namespace)
+		mine := userId != "" && r.URL.Query().Get("mine") == "true"
+		p := parseListParams(r)
+		raw, cursor, paged, dbErr := s.loadNamespace(r.Context(), namespace, p, mine)
 		if dbErr != nil {
 			switch dbErr.ErrorCode {
 			case database.NAMESPACE_NOT_FOUND:
@@ -143,16 +207,77 @@ func (s *Server) namespaceHandler(w http.ResponseWriter, r *http.Request) {
 			default:
 				respondWithError(w, http.StatusInternalServerError, dbErr.Error())
 			}
+			return
+		}
+		if mine {
+			raw = filterMine(raw, userId)
+			paged = false
+		}
+
+		docs := make(map[string]interface{}, len(raw))
+		keys := make([]string, 0, len(raw))
+		for k, v := range raw {
+			var doc interface{}
+			if err := json.Unmarshal(v, &doc); err != nil {
+				doc = string(v)
+			}
+			docs[k] = doc
+			keys = append(keys, k)
+		}
+
+		nextCursor := cursor
+		if !paged {
+			sortKeys(keys, docs, p)
+			keys, nextCursor = paginateKeys(keys, p.cursor, p.limit)
+		}
+
+		items := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			items[k] = project(docs[k], p.project)
 		}
-		namespaceData, err := jsonWrapper(data)
+
+		resp, err := json.Marshal(listResponse{Items: items, NextCursor: nextCursor})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		respondWithJSON(w, http.StatusOK, string(namespaceData))
+		respondWithJSON(w, http.StatusOK, string(resp))
 
 	case http.MethodDelete:
-		dbErr := s.db.DeleteAll(namespace)
+		if userId != "" && !s.users.CanWrite(r.Context(), namespace, userId) {
+			respondWithError(w, http.StatusForbidden, "namespace is owned by another user")
+			return
+		}
+
+		if r.URL.Query().Get("async") == "true" {
+			op, err := s.operations.Start(r.Context(), "DELETE_NAMESPACE", func(ctx context.Context, progress func(int)) (interface{}, error) {
+				if dbErr := s.db.DeleteAll(ctx, namespace); dbErr != nil {
+					return nil, dbErr
+				}
+				if userId != "" {
+					s.users.Claim(ctx, namespace, userId)
+				}
+				s.Notify(BrokerEvent{
+					Event:     EVENT_NAMESPACE_DELETED,
+					User:      userId,
+					Namespace: namespace,
+				})
+				return nil, nil
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			opData, err := json.Marshal(op)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			respondWithJSON(w, http.StatusAccepted, string(opData))
+			return
+		}
+
+		dbErr := s.db.DeleteAll(r.Context(), namespace)
 		if dbErr != nil {
 			switch dbErr.ErrorCode {
 			case database.NAMESPACE_NOT_FOUND:
@@ -160,6 +285,10 @@ func (s *Server) namespaceHandler(w http.ResponseWriter, r *http.Request) {
 			default:
 				respondWithError(w, http.StatusInternalServerError, dbErr.Error())
 			}
+			return
+		}
+		if userId != "" {
+			s.users.Claim(r.Context(), namespace, userId)
 		}
 		s.Notify(BrokerEvent{
 			Event:     EVENT_NAMESPACE_DELETED,
@@ -194,7 +323,12 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		parsedData, err := s.validate(namespace, data)
+		if userId != "" && !s.users.CanWrite(r.Context(), namespace, userId) {
+			respondWithError(w, http.StatusForbidden, "namespace is owned by another user")
+			return
+		}
+
+		parsedData, err := s.validate(r.Context(), namespace, data)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
@@ -214,7 +348,7 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		dbErr := s.db.Upsert(namespace, key, data)
+		dbErr := s.db.Upsert(r.Context(), namespace, key, data)
 		if dbErr != nil {
 			switch dbErr.ErrorCode {
 			case database.NAMESPACE_NOT_FOUND:
@@ -224,6 +358,9 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		if userId != "" {
+			s.users.Claim(r.Context(), namespace, userId)
+		}
 		s.Notify(BrokerEvent{
 			Event:     EVENT_ITEM_ADDED,
 			User:      userId,
@@ -233,7 +370,7 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 		})
 		respondWithJSON(w, http.StatusCreated, string(data))
 	case http.MethodGet:
-		data, dbErr := s.db.Get(namespace, key)
+		data, dbErr := s.db.Get(r.Context(), namespace, key)
 		if dbErr != nil {
 			switch dbErr.ErrorCode {
 			case database.ID_NOT_FOUND:
@@ -247,7 +384,11 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		respondWithJSON(w, http.StatusOK, string(data))
 	case http.MethodDelete:
-		err := s.db.Delete(namespace, key)
+		if userId != "" && !s.users.CanWrite(r.Context(), namespace, userId) {
+			respondWithError(w, http.StatusForbidden, "namespace is owned by another user")
+			return
+		}
+		err := s.db.Delete(r.Context(), namespace, key)
 		if err != nil {
 
 			switch err.ErrorCode {
@@ -260,6 +401,9 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		if userId != "" {
+			s.users.Claim(r.Context(), namespace, userId)
+		}
 		s.Notify(BrokerEvent{
 			Event:     EVENT_ITEM_DELETED,
 			User:      userId,
@@ -272,6 +416,12 @@ func (s *Server) keyValueHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) schemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
 	vars := mux.Vars(r)
 	namespace := vars["namespace"] + SchemaId
 
@@ -285,22 +435,50 @@ func (s *Server) schemaHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		dbErr := s.db.Upsert(namespace, SchemaId, data)
+		dbErr := s.db.Upsert(r.Context(), namespace, SchemaId, data)
 		if dbErr != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		log.Printf("added schema for namespace '%s'\n", vars["namespace"])
 		respondWithJSON(w, http.StatusCreated, string(data))
+	case http.MethodPut:
+		existing, dbErr := s.db.Get(r.Context(), namespace, SchemaId)
+		currentFingerprint := ""
+		if dbErr == nil {
+			currentFingerprint = schemaFingerprint(existing)
+		}
+		if r.Header.Get("If-Match") != currentFingerprint {
+			respondWithError(w, http.StatusConflict, "schema If-Match fingerprint does not match the current schema; re-fetch GET /schema/{namespace} and retry")
+			return
+		}
+
+		defer r.Body.Close()
+		r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dbErr = s.db.Upsert(r.Context(), namespace, SchemaId, data)
+		if dbErr != nil {
+			respondWithError(w, http.StatusBadRequest, dbErr.Error())
+			return
+		}
+		log.Printf("updated schema for namespace '%s'\n", vars["namespace"])
+		w.Header().Set("ETag", schemaFingerprint(data))
+		respondWithJSON(w, http.StatusOK, string(data))
 	case http.MethodGet:
-		data, dbErr := s.db.Get(namespace, SchemaId)
+		data, dbErr := s.db.Get(r.Context(), namespace, SchemaId)
 		if dbErr != nil {
 			respondWithError(w, http.StatusNotFound, dbErr.Error())
 			return
 		}
+		w.Header().Set("ETag", schemaFingerprint(data))
 		respondWithJSON(w, http.StatusOK, string(data))
 	case http.MethodDelete:
-		dbErr := s.db.Delete(namespace, SchemaId)
+		dbErr := s.db.Delete(r.Context(), namespace, SchemaId)
 		if dbErr != nil {
 			respondWithError(w, http.StatusNotFound, dbErr.Error())
 			return
@@ -309,6 +487,13 @@ func (s *Server) schemaHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// schemaFingerprint is the ETag/If-Match value for a stored schema,
+// used by PUT /schema/{namespace} to reject a write based on a stale read.
+func schemaFingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "*")
@@ -316,14 +501,9 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := struct {
-		Results []interface{} `json:"results"`
-	}{
-		Results: make([]interface{}, 0),
-	}
-
 	switch r.Method {
 	case http.MethodGet:
+		ctx := r.Context()
 		vars := mux.Vars(r)
 		query, err := gojq.Parse(vars["filter"])
 		if err != nil {
@@ -331,13 +511,31 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		data, dbErr := s.db.GetAll(vars["namespace"])
+
+		p := parseListParams(r)
+		// forceFull: limit/cursor must bound the filtered matches, not the raw
+		// documents scanned to produce them, so RangeAll's own pagination
+		// (which knows nothing about the filter) can't be used here.
+		data, cursor, paged, dbErr := s.loadNamespace(ctx, vars["namespace"], p, true)
 		if dbErr != nil {
-			log.Println("error on GetAll", err)
+			if ctx.Err() != nil {
+				respondWithError(w, http.StatusGatewayTimeout, ctx.Err().Error())
+				return
+			}
+			log.Println("error on GetAll", dbErr)
 			respondWithError(w, http.StatusBadRequest, dbErr.Error())
 			return
 		}
+
+		entries := make([]searchEntry, 0)
 		for key, value := range data {
+			select {
+			case <-ctx.Done():
+				respondWithError(w, http.StatusGatewayTimeout, ctx.Err().Error())
+				return
+			default:
+			}
+
 			var jsonContent map[string]interface{}
 			err := json.Unmarshal(value, &jsonContent)
 			if err != nil {
@@ -355,25 +553,54 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 					respondWithError(w, http.StatusInternalServerError, err.Error())
 					return
 				}
-				result.Results = append(result.Results, map[string]interface{}{"key": key, "value": v})
+				entries = append(entries, searchEntry{Key: key, Value: v})
 			}
 		}
-		jsonResponse, _ := json.Marshal(result)
+
+		nextCursor := cursor
+		if !paged {
+			sortEntries(entries, p)
+			entries, nextCursor = paginateEntries(entries, p.cursor, p.limit)
+		}
+		for i := range entries {
+			entries[i].Value = project(entries[i].Value, p.project)
+		}
+
+		jsonResponse, _ := json.Marshal(searchResponse{Items: entries, NextCursor: nextCursor})
 		respondWithJSON(w, http.StatusOK, string(jsonResponse))
 	}
 }
 
 func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
-	namespaces := s.db.GetNamespaces()
-
-	rootMap, err := s.generateOpenAPIMap(namespaces)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if !s.config.Current().SwaggerEnabled {
+		respondWithError(w, http.StatusNotFound, "swagger is disabled")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
+		if r.URL.Query().Get("async") == "true" {
+			op, err := s.operations.Start(r.Context(), "GENERATE_OPENAPI", func(ctx context.Context, progress func(int)) (interface{}, error) {
+				return s.generateOpenAPIMap(s.db.GetNamespaces())
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			opData, err := json.Marshal(op)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			respondWithJSON(w, http.StatusAccepted, string(opData))
+			return
+		}
+
+		rootMap, err := s.generateOpenAPIMap(s.db.GetNamespaces())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		output, err := json.MarshalIndent(rootMap, "", "  ")
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
@@ -391,11 +618,11 @@ func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
 
 // utils
 
-func (s *Server) validate(namespace string, data []byte) (interface{}, error) {
+func (s *Server) validate(ctx context.Context, namespace string, data []byte) (interface{}, error) {
 	var parsed interface{}
 
 	// if namespace has a schema, validate against it
-	schemaJson, dbErr := s.db.Get(namespace+SchemaId, SchemaId)
+	schemaJson, dbErr := s.db.Get(ctx, namespace+SchemaId, SchemaId)
 	if dbErr == nil {
 		schemaLoader := gojsonschema.NewBytesLoader(schemaJson)
 		documentLoader := gojsonschema.NewBytesLoader(data)
@@ -427,8 +654,23 @@ func (s *Server) validate(namespace string, data []byte) (interface{}, error) {
 	return parsed, nil
 }
 
+// filterMine narrows data to the entries whose Payload.User matches userId.
+// Entries without a Payload envelope (i.e. stored with auth disabled) are
+// dropped, since ownership can't be determined for them.
+func filterMine(data map[string][]byte, userId string) map[string][]byte {
+	filtered := make(map[string][]byte)
+	for key, value := range data {
+		var payload Payload
+		if err := json.Unmarshal(value, &payload); err != nil || payload.User != userId {
+			continue
+		}
+		filtered[key] = value
+	}
+	return filtered
+}
+
 func (s *Server) Notify(event BrokerEvent) {
-	if s.broker != nil {
+	if s.broker != nil && s.config.Current().BrokerEnabled {
 		jsonData, _ := json.Marshal(event)
 		s.broker.Notifier <- jsonData
 	}
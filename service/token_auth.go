@@ -0,0 +1,38 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const bearerPrefix = "Bearer "
+
+// tokenOrJWTMiddleware resolves a bearer token issued by POST /users before
+// falling through to jwtNext, so an issued token authenticates a request on
+// its own - independent of however strictly jwtNext treats anything that
+// isn't a valid JWT - letting tokens and JWTs be used interchangeably.
+func (s *Server) tokenOrJWTMiddleware(jwtNext mux.MiddlewareFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		wrapped := jwtNext(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := bearerToken(r); token != "" {
+				if user, ok := s.users.ResolveToken(r.Context(), token); ok {
+					r.Header.Set(USER_HEADER, user.ID)
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, bearerPrefix)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rehacktive/caffeine/database"
+)
+
+const (
+	UploadUUIDHeader = "Upload-UUID"
+	// maxUploadChunkSize bounds a single PATCH's body, as the (smaller) 1 MiB
+	// cap keyValueHandler applies bounds a whole value written in one request.
+	maxUploadChunkSize = 8 << 20 // 8 MiB
+	// maxUploadTotalSize bounds a whole upload session, so StartUpload can't
+	// be used to stage an arbitrarily large file chunk by chunk.
+	maxUploadTotalSize = 512 << 20 // 512 MiB
+)
+
+// startUploadHandler opens a resumable upload session for a value too large
+// for the regular keyValueHandler POST, modeled on the Docker distribution
+// blob-writer flow: the caller streams the body in PATCH-appended chunks and
+// finalizes with a PUT once the digest is known.
+func (s *Server) startUploadHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	userId := r.Header.Get(USER_HEADER)
+
+	vars := mux.Vars(r)
+	namespace := vars["namespace"]
+	key := vars["key"]
+
+	if userId != "" && !s.users.CanWrite(r.Context(), namespace, userId) {
+		respondWithError(w, http.StatusForbidden, "namespace is owned by another user")
+		return
+	}
+
+	uploadId, dbErr := s.db.StartUpload(r.Context(), namespace, key)
+	if dbErr != nil {
+		respondWithError(w, http.StatusInternalServerError, dbErr.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/ns/%s/%s/uploads/%s", namespace, key, uploadId))
+	w.Header().Set(UploadUUIDHeader, uploadId)
+	w.Header().Set("Range", "bytes=0-0")
+	respondWithJSON(w, http.StatusAccepted, "{}")
+}
+
+// uploadHandler appends a chunk (PATCH), finalizes (PUT), or discards
+// (DELETE) an open upload session.
+func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	userId := r.Header.Get(USER_HEADER)
+
+	vars := mux.Vars(r)
+	namespace := vars["namespace"]
+	key := vars["key"]
+	uploadId := vars["uuid"]
+
+	switch r.Method {
+	case http.MethodPatch:
+		start, err := parseContentRangeStart(r.Header.Get("Content-Range"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if start >= maxUploadTotalSize {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds the %d byte session limit", maxUploadTotalSize))
+			return
+		}
+
+		defer r.Body.Close()
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadChunkSize)
+		chunk, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if start+int64(len(chunk)) > maxUploadTotalSize {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds the %d byte session limit", maxUploadTotalSize))
+			return
+		}
+
+		size, dbErr := s.db.AppendUpload(r.Context(), uploadId, start, chunk)
+		if dbErr != nil {
+			respondWithUploadError(w, dbErr)
+			return
+		}
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+		respondWithJSON(w, http.StatusAccepted, "{}")
+
+	case http.MethodPut:
+		if userId != "" && !s.users.CanWrite(r.Context(), namespace, userId) {
+			respondWithError(w, http.StatusForbidden, "namespace is owned by another user")
+			return
+		}
+
+		data, dbErr := s.db.FinishUpload(r.Context(), uploadId, r.URL.Query().Get("digest"))
+		if dbErr != nil {
+			respondWithUploadError(w, dbErr)
+			return
+		}
+
+		parsedData, err := s.validate(r.Context(), namespace, data)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if s.AuthEnabled {
+			payload := Payload{
+				User: userId,
+				Data: parsedData,
+			}
+			data, err = payload.wrap()
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		if dbErr := s.db.Upsert(r.Context(), namespace, key, data); dbErr != nil {
+			respondWithError(w, http.StatusInternalServerError, dbErr.Error())
+			return
+		}
+		if userId != "" {
+			s.users.Claim(r.Context(), namespace, userId)
+		}
+		s.Notify(BrokerEvent{
+			Event:     EVENT_ITEM_ADDED,
+			User:      userId,
+			Namespace: namespace,
+			Key:       key,
+			Value:     parsedData,
+		})
+		respondWithJSON(w, http.StatusCreated, string(data))
+
+	case http.MethodDelete:
+		if dbErr := s.db.CancelUpload(r.Context(), uploadId); dbErr != nil {
+			respondWithUploadError(w, dbErr)
+			return
+		}
+		respondWithJSON(w, http.StatusAccepted, "{}")
+	}
+}
+
+func respondWithUploadError(w http.ResponseWriter, dbErr *database.DbError) {
+	switch dbErr.ErrorCode {
+	case database.ID_NOT_FOUND:
+		respondWithError(w, http.StatusNotFound, dbErr.Error())
+	default:
+		respondWithError(w, http.StatusBadRequest, dbErr.Error())
+	}
+}
+
+// parseContentRangeStart parses the start offset out of a "start-end"
+// Content-Range value, as sent by PATCH against an open upload.
+func parseContentRangeStart(header string) (int64, error) {
+	parts := strings.SplitN(header, "-", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid Content-Range '%v'", header)
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range '%v'", header)
+	}
+	return start, nil
+}
@@ -0,0 +1,171 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rehacktive/caffeine/database"
+)
+
+const (
+	// UsersNamespace holds registered accounts, keyed by user id.
+	UsersNamespace = "_users"
+	// AclNamespace holds the namespace->owner (user id) mapping.
+	AclNamespace = "_acl"
+)
+
+// Store is the subset of service.Database that the users subsystem needs. It
+// is declared here, rather than imported, to avoid a service<->users import
+// cycle since service wires the users routes and middleware.
+type Store interface {
+	Upsert(ctx context.Context, namespace string, key string, value []byte) *database.DbError
+	Get(ctx context.Context, namespace string, key string) ([]byte, *database.DbError)
+	GetAll(ctx context.Context, namespace string) (map[string][]byte, *database.DbError)
+	Delete(ctx context.Context, namespace string, key string) *database.DbError
+}
+
+// User is a registered account. TokenHash is the sha256 of the bearer token
+// handed to the caller on registration; the raw token is never persisted.
+type User struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	TokenHash string `json:"token_hash"`
+}
+
+// Manager persists users and namespace ownership through a Store.
+type Manager struct {
+	db Store
+}
+
+func NewManager(db Store) *Manager {
+	return &Manager{db: db}
+}
+
+// Register creates a new user and returns it along with the bearer token to
+// hand back to the caller (this is the only time the raw token is available).
+func (m *Manager) Register(ctx context.Context, email string) (User, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return User{}, "", err
+	}
+	id, err := newUserId()
+	if err != nil {
+		return User{}, "", err
+	}
+	user := User{
+		ID:        id,
+		Email:     email,
+		TokenHash: hashToken(token),
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return User{}, "", err
+	}
+	if dbErr := m.db.Upsert(ctx, UsersNamespace, user.ID, data); dbErr != nil {
+		return User{}, "", dbErr
+	}
+	return user, token, nil
+}
+
+func (m *Manager) Get(ctx context.Context, id string) (User, error) {
+	data, dbErr := m.db.Get(ctx, UsersNamespace, id)
+	if dbErr != nil {
+		return User{}, dbErr
+	}
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	if dbErr := m.db.Delete(ctx, UsersNamespace, id); dbErr != nil {
+		return dbErr
+	}
+	return nil
+}
+
+// ResolveToken looks up the user owning token, for use by auth middleware.
+func (m *Manager) ResolveToken(ctx context.Context, token string) (User, bool) {
+	if token == "" {
+		return User{}, false
+	}
+	all, dbErr := m.db.GetAll(ctx, UsersNamespace)
+	if dbErr != nil {
+		return User{}, false
+	}
+	hash := hashToken(token)
+	for _, data := range all {
+		var user User
+		if err := json.Unmarshal(data, &user); err != nil {
+			continue
+		}
+		if user.TokenHash == hash {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// Owner returns the user id owning namespace, if any write has claimed it yet.
+func (m *Manager) Owner(ctx context.Context, namespace string) (string, bool) {
+	data, dbErr := m.db.Get(ctx, AclNamespace, namespace)
+	if dbErr != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// CanWrite reports whether userId may write to namespace: true if no one has
+// claimed it yet, or if userId is already its owner. It does not claim
+// namespace itself - call Claim once the write it's guarding actually
+// succeeds, so a request rejected for other reasons can't claim a namespace
+// out from under its real owner.
+func (m *Manager) CanWrite(ctx context.Context, namespace string, userId string) bool {
+	owner, ok := m.Owner(ctx, namespace)
+	if !ok {
+		return true
+	}
+	return owner == userId
+}
+
+// Claim records userId as namespace's owner, if it doesn't have one yet.
+func (m *Manager) Claim(ctx context.Context, namespace string, userId string) error {
+	if _, ok := m.Owner(ctx, namespace); ok {
+		return nil
+	}
+	if dbErr := m.db.Upsert(ctx, AclNamespace, namespace, []byte(userId)); dbErr != nil {
+		return dbErr
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// newUserId generates a public user id independently of the bearer token, so
+// the id handed back by GET /users/{id} and stored in _acl/Payload.User never
+// reveals any part of the secret token.
+func newUserId() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating user id: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}